@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCutOnce(t *testing.T) {
+	tests := []struct {
+		s, sep        string
+		before, after string
+		found         bool
+	}{
+		{s: "sha256=abcd", sep: "=", before: "sha256", after: "abcd", found: true},
+		{s: "a=b=c", sep: "=", before: "a", after: "b=c", found: true},
+		{s: "noseparator", sep: "=", before: "", after: "", found: false},
+		{s: "", sep: "=", before: "", after: "", found: false},
+	}
+	for _, tt := range tests {
+		before, after, found := cutOnce(tt.s, tt.sep)
+		if before != tt.before || after != tt.after || found != tt.found {
+			t.Fatalf("cutOnce(%q, %q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.s, tt.sep, before, after, found, tt.before, tt.after, tt.found)
+		}
+	}
+}
+
+func TestParseChecksumHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  map[string][]byte
+	}{
+		{
+			name:  "empty header",
+			value: "",
+			want:  nil,
+		},
+		{
+			name:  "single algorithm",
+			value: "sha256=abcd",
+			want:  map[string][]byte{"sha256": {0xab, 0xcd}},
+		},
+		{
+			name:  "multiple algorithms with whitespace",
+			value: "sha256=abcd, md5=ef01",
+			want:  map[string][]byte{"sha256": {0xab, 0xcd}, "md5": {0xef, 0x01}},
+		},
+		{
+			name:  "entry with no separator is skipped",
+			value: "sha256=abcd,garbage",
+			want:  map[string][]byte{"sha256": {0xab, 0xcd}},
+		},
+		{
+			name:  "entry with malformed hex is skipped",
+			value: "sha256=zz,md5=ef01",
+			want:  map[string][]byte{"md5": {0xef, 0x01}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseChecksumHeader(tt.value)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseChecksumHeader(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			for algo, want := range tt.want {
+				have, ok := got[algo]
+				if !ok {
+					t.Fatalf("parseChecksumHeader(%q): missing algorithm %q", tt.value, algo)
+				}
+				if !bytes.Equal(have, want) {
+					t.Fatalf("parseChecksumHeader(%q): algorithm %q = %x, want %x", tt.value, algo, have, want)
+				}
+			}
+		})
+	}
+}
@@ -24,3 +24,9 @@ func (c *Client) ConsensusBlocksHeightGet(height types.BlockHeight) (block types
 	err = c.Get("/consensus/blocks?height="+strconv.FormatUint(uint64(height), 10), &block)
 	return
 }
+
+// ConsensusSyncProgressGet requests the /consensus/syncprogress api resource
+func (c *Client) ConsensusSyncProgressGet() (csp api.ConsensusSyncProgressGET, err error) {
+	err = c.Get("/consensus/syncprogress", &csp)
+	return
+}
@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/errors"
+)
+
+const (
+	// DefaultDownloadPartSize is the size of a single segmented download part
+	// used when a DownloadOptions value doesn't specify one.
+	DefaultDownloadPartSize = uint64(1 << 22) // 4 MiB
+
+	// DefaultDownloadConcurrency is the number of parts downloaded in
+	// parallel when a DownloadOptions value doesn't specify one.
+	DefaultDownloadConcurrency = 4
+
+	// DefaultDownloadMaxRetries is the number of attempts, the first one
+	// included, a single part gets before the download is aborted.
+	DefaultDownloadMaxRetries = 5
+)
+
+// PartProgress describes the state of a single in-flight or completed part
+// of a segmented download.
+type PartProgress struct {
+	Index   int
+	Offset  uint64
+	Length  uint64
+	Fetched uint64
+	Attempt int
+	Done    bool
+	Err     error
+}
+
+// ProgressFunc is called by DownloadFile every time a part makes progress,
+// so that callers can render per-part throughput.
+type ProgressFunc func(PartProgress)
+
+// DownloadOptions configures a segmented, concurrent download performed by
+// Client.DownloadFile.
+type DownloadOptions struct {
+	// Concurrency is the number of parts downloaded at the same time.
+	Concurrency int
+	// PartSize is the size of each downloaded part in bytes.
+	PartSize uint64
+	// MaxRetries is the number of attempts, the first one included, a single
+	// part gets before the overall download is aborted.
+	MaxRetries int
+	// Context, if set, is used to cancel all in-flight parts.
+	Context context.Context
+	// OnProgress, if set, is called after every part attempt.
+	OnProgress ProgressFunc
+}
+
+// fillDefaults returns a copy of opts with zero-valued fields replaced by
+// their defaults.
+func (opts DownloadOptions) fillDefaults() DownloadOptions {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultDownloadConcurrency
+	}
+	if opts.PartSize == 0 {
+		opts.PartSize = DefaultDownloadPartSize
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = DefaultDownloadMaxRetries
+	}
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+	return opts
+}
+
+// part describes a single byte range of a segmented download.
+type part struct {
+	index  int
+	offset uint64
+	length uint64
+}
+
+// partFetcher is the subset of *Client that downloadPart needs to fetch a
+// single part. It exists so tests can exercise DownloadFile/downloadPart's
+// retry, backoff, and cancellation behavior with a fake that doesn't hit the
+// network.
+type partFetcher interface {
+	RenterDownloadHTTPResponseGet(siaPath string, offset, length uint64) ([]byte, error)
+}
+
+// splitParts splits a file of the given size into fixed-size parts.
+func splitParts(filesize uint64, partSize uint64) []part {
+	var parts []part
+	for offset, index := uint64(0), 0; offset < filesize; index++ {
+		length := partSize
+		if offset+length > filesize {
+			length = filesize - offset
+		}
+		parts = append(parts, part{index: index, offset: offset, length: length})
+		offset += length
+	}
+	return parts
+}
+
+// DownloadFile downloads siaPath to destination by splitting it into fixed
+// size parts and fetching them concurrently via the
+// /renter/downloadhttpresponse endpoint, retrying each part independently
+// with exponential backoff. The download can be aborted early by cancelling
+// opts.Context. DownloadFile always waits for every dispatched part to
+// return before closing destination, so "aborting" never leaves a goroutine
+// writing to an already-closed file.
+func (c *Client) DownloadFile(siaPath string, destination string, filesize uint64, opts DownloadOptions) error {
+	return downloadFile(c, siaPath, destination, filesize, opts)
+}
+
+// downloadFile is DownloadFile's implementation, taking a partFetcher
+// instead of a concrete *Client so tests can exercise it with a fake.
+func downloadFile(fetcher partFetcher, siaPath string, destination string, filesize uint64, opts DownloadOptions) error {
+	opts = opts.fillDefaults()
+
+	f, err := os.Create(destination)
+	if err != nil {
+		return errors.AddContext(err, "failed to create destination file")
+	}
+
+	parts := splitParts(filesize, opts.PartSize)
+	sem := make(chan struct{}, opts.Concurrency)
+	errChan := make(chan error, len(parts))
+	var wg sync.WaitGroup
+
+	var dispatchErr error
+	dispatched := 0
+dispatchLoop:
+	for _, p := range parts {
+		select {
+		case <-opts.Context.Done():
+			dispatchErr = opts.Context.Err()
+			break dispatchLoop
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		dispatched++
+		go func(p part) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errChan <- downloadPart(fetcher, f, siaPath, p, opts)
+		}(p)
+	}
+
+	var partErr error
+	for i := 0; i < dispatched; i++ {
+		if err := <-errChan; err != nil && partErr == nil {
+			partErr = err
+		}
+	}
+	// Every dispatched goroutine has reported its result by now, so it's
+	// safe to close the file even if dispatching was cut short above.
+	wg.Wait()
+	closeErr := f.Close()
+
+	if dispatchErr != nil {
+		return dispatchErr
+	}
+	if partErr != nil {
+		// A part that stopped because opts.Context was cancelled reports the
+		// cancellation itself, unwrapped.
+		if partErr == opts.Context.Err() {
+			return partErr
+		}
+		return errors.AddContext(partErr, "part download failed")
+	}
+	return closeErr
+}
+
+// downloadPart fetches a single part, retrying with exponential backoff on
+// transient errors until opts.MaxRetries is exhausted or opts.Context is
+// cancelled.
+func downloadPart(fetcher partFetcher, f *os.File, siaPath string, p part, opts DownloadOptions) error {
+	backoff := 250 * time.Millisecond
+	var lastErr error
+	for attempt := 1; attempt <= opts.MaxRetries; attempt++ {
+		select {
+		case <-opts.Context.Done():
+			return opts.Context.Err()
+		default:
+		}
+
+		data, err := fetcher.RenterDownloadHTTPResponseGet(siaPath, p.offset, p.length)
+		if err == nil {
+			_, err = f.WriteAt(data, int64(p.offset))
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(PartProgress{
+				Index:   p.index,
+				Offset:  p.offset,
+				Length:  p.length,
+				Fetched: uint64(len(data)),
+				Attempt: attempt,
+				Done:    err == nil,
+				Err:     err,
+			})
+		}
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-opts.Context.Done():
+			return opts.Context.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return errors.AddContext(lastErr, "exhausted retries")
+}
+
+// checksumHeader is the response header the renter uses to advertise
+// third-party checksums of the bytes a /renter/downloadhttpresponse request
+// returned, e.g. "X-Sia-Hash: sha256=<hex>,md5=<hex>".
+const checksumHeader = "X-Sia-Hash"
+
+// RenterDownloadHTTPResponseGetWithChecksums behaves like
+// RenterDownloadHTTPResponseGet, except it also returns every checksum the
+// renter advertised for the returned bytes via checksumHeader, keyed by
+// algorithm name. The returned map is empty whenever the server doesn't set
+// checksumHeader on its response - nothing in this tree's renter module
+// populates it yet, so today this always returns an empty map. Once a
+// server does start advertising checksums this way, callers can use the
+// result to verify a download against hashes the server actually computed,
+// rather than only replaying a hash the client computed itself before
+// upload.
+func (c *Client) RenterDownloadHTTPResponseGetWithChecksums(siaPath string, offset, length uint64) (data []byte, checksums map[string][]byte, err error) {
+	header, data, err := c.getRawResponse(fmt.Sprintf("/renter/downloadhttpresponse?siapath=%s&offset=%d&length=%d", siaPath, offset, length))
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, parseChecksumHeader(header.Get(checksumHeader)), nil
+}
+
+// parseChecksumHeader parses a checksumHeader value of the form
+// "algo=hexdigest,algo=hexdigest,...", skipping any entry it can't parse.
+func parseChecksumHeader(value string) map[string][]byte {
+	if value == "" {
+		return nil
+	}
+	checksums := make(map[string][]byte)
+	for _, entry := range strings.Split(value, ",") {
+		algo, hexDigest, ok := cutOnce(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		digest, err := hex.DecodeString(hexDigest)
+		if err != nil {
+			continue
+		}
+		checksums[algo] = digest
+	}
+	return checksums
+}
+
+// cutOnce splits s on the first occurrence of sep, returning false if sep
+// isn't present. It's the equivalent of strings.Cut, written out since this
+// module doesn't assume a Go version new enough to have it.
+func cutOnce(s, sep string) (before, after string, found bool) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
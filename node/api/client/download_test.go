@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/errors"
+)
+
+// TestSplitParts checks splitParts' boundary handling: even divisions,
+// remainders, and file sizes smaller than a single part.
+func TestSplitParts(t *testing.T) {
+	tests := []struct {
+		filesize, partSize uint64
+		wantLengths        []uint64
+	}{
+		{filesize: 0, partSize: 10, wantLengths: nil},
+		{filesize: 10, partSize: 10, wantLengths: []uint64{10}},
+		{filesize: 25, partSize: 10, wantLengths: []uint64{10, 10, 5}},
+		{filesize: 5, partSize: 10, wantLengths: []uint64{5}},
+	}
+	for _, tt := range tests {
+		parts := splitParts(tt.filesize, tt.partSize)
+		if len(parts) != len(tt.wantLengths) {
+			t.Fatalf("splitParts(%d, %d): got %d parts, want %d", tt.filesize, tt.partSize, len(parts), len(tt.wantLengths))
+		}
+		var offset uint64
+		for i, p := range parts {
+			if p.index != i {
+				t.Fatalf("part %d: index = %d, want %d", i, p.index, i)
+			}
+			if p.offset != offset {
+				t.Fatalf("part %d: offset = %d, want %d", i, p.offset, offset)
+			}
+			if p.length != tt.wantLengths[i] {
+				t.Fatalf("part %d: length = %d, want %d", i, p.length, tt.wantLengths[i])
+			}
+			offset += p.length
+		}
+		if offset != tt.filesize {
+			t.Fatalf("splitParts(%d, %d): parts cover %d bytes, want %d", tt.filesize, tt.partSize, offset, tt.filesize)
+		}
+	}
+}
+
+// fakeFetcher is a partFetcher whose behavior per call is driven by fn, so
+// tests can script failures, successes, and blocking calls.
+type fakeFetcher struct {
+	mu    sync.Mutex
+	calls int
+	fn    func(calls int, siaPath string, offset, length uint64) ([]byte, error)
+}
+
+func (f *fakeFetcher) RenterDownloadHTTPResponseGet(siaPath string, offset, length uint64) ([]byte, error) {
+	f.mu.Lock()
+	f.calls++
+	calls := f.calls
+	f.mu.Unlock()
+	return f.fn(calls, siaPath, offset, length)
+}
+
+func (f *fakeFetcher) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// TestDownloadFileRetriesOnTransientError verifies a part that fails a
+// bounded number of times before succeeding is retried with backoff rather
+// than aborting the whole download.
+func TestDownloadFileRetriesOnTransientError(t *testing.T) {
+	const failures = 2
+	fetcher := &fakeFetcher{fn: func(calls int, siaPath string, offset, length uint64) ([]byte, error) {
+		if calls <= failures {
+			return nil, errors.New("transient fetch error")
+		}
+		return make([]byte, length), nil
+	}}
+
+	dest := tempFile(t)
+	defer os.Remove(dest)
+
+	err := downloadFile(fetcher, "testpath", dest, 10, DownloadOptions{
+		PartSize:   10,
+		MaxRetries: failures + 1,
+	})
+	if err != nil {
+		t.Fatalf("downloadFile: unexpected error: %v", err)
+	}
+	if got, want := fetcher.callCount(), failures+1; got != want {
+		t.Fatalf("fetch called %d times, want %d", got, want)
+	}
+}
+
+// TestDownloadFileExhaustsRetries verifies a part that never succeeds
+// aborts the download once MaxRetries is spent, instead of retrying
+// forever.
+func TestDownloadFileExhaustsRetries(t *testing.T) {
+	fetcher := &fakeFetcher{fn: func(calls int, siaPath string, offset, length uint64) ([]byte, error) {
+		return nil, errors.New("permanent fetch error")
+	}}
+
+	dest := tempFile(t)
+	defer os.Remove(dest)
+
+	err := downloadFile(fetcher, "testpath", dest, 10, DownloadOptions{
+		PartSize:   10,
+		MaxRetries: 3,
+	})
+	if err == nil {
+		t.Fatal("downloadFile: expected an error, got nil")
+	}
+	if got, want := fetcher.callCount(), 3; got != want {
+		t.Fatalf("fetch called %d times, want %d (exactly MaxRetries attempts)", got, want)
+	}
+}
+
+// TestDownloadFileCancelAbortsInFlightParts verifies that cancelling
+// opts.Context stops in-flight parts from retrying further and makes
+// DownloadFile return promptly instead of waiting for every part to
+// exhaust its retries.
+func TestDownloadFileCancelAbortsInFlightParts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls int32
+	fetcher := &fakeFetcher{fn: func(n int, siaPath string, offset, length uint64) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			// Cancel partway through the first part's retry loop so the
+			// remaining attempts (for this part and any others) observe
+			// a cancelled context instead of retrying.
+			cancel()
+		}
+		return nil, errors.New("fetch error")
+	}}
+
+	dest := tempFile(t)
+	defer os.Remove(dest)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- downloadFile(fetcher, "testpath", dest, 40, DownloadOptions{
+			PartSize:   10,
+			MaxRetries: 1000,
+			Context:    ctx,
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("downloadFile: got error %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("downloadFile did not return after its context was cancelled")
+	}
+}
+
+// tempFile returns a path to a freshly created, empty temp file for
+// DownloadFile to write into.
+func tempFile(t *testing.T) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "download-test-")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer f.Close()
+	return f.Name()
+}
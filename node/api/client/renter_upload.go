@@ -0,0 +1,29 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/NebulousLabs/Sia/node/api"
+)
+
+// RenterUnfinishedUploadsGet requests the /renter/uploads/unfinished api
+// resource, returning the chunks of in-progress or interrupted uploads that
+// the renter has already persisted and can resume from.
+func (c *Client) RenterUnfinishedUploadsGet() ([]api.UnfinishedUpload, error) {
+	var rug api.RenterUnfinishedUploadsGET
+	if err := c.Get("/renter/uploads/unfinished", &rug); err != nil {
+		return nil, err
+	}
+	return rug.Uploads, nil
+}
+
+// RenterResumeUploadPost requests the /renter/uploads/{id}/resume api
+// resource, resuming the upload identified by id from localPath. Chunks the
+// renter already has a persisted manifest entry for are verified by hash
+// and skipped rather than re-uploaded.
+func (c *Client) RenterResumeUploadPost(id, localPath string) error {
+	values := url.Values{}
+	values.Set("localpath", localPath)
+	return c.Post(fmt.Sprintf("/renter/uploads/%s/resume", id), values.Encode(), nil)
+}
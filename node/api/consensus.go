@@ -0,0 +1,16 @@
+package api
+
+import (
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// ConsensusSyncProgressGET contains the /consensus/syncprogress GET API
+// response, reporting how far consensus has synced against its current view
+// of the blockchain's height.
+type ConsensusSyncProgressGET struct {
+	StartingBlock types.BlockHeight `json:"startingblock"`
+	CurrentBlock  types.BlockHeight `json:"currentblock"`
+	HighestBlock  types.BlockHeight `json:"highestblock"`
+	PulledBlocks  uint64            `json:"pulledblocks"`
+	KnownBlocks   uint64            `json:"knownblocks"`
+}
@@ -0,0 +1,19 @@
+package api
+
+// UnfinishedUpload describes a single chunk of an in-progress or interrupted
+// upload that the renter has already persisted, so that a resumed upload
+// can skip re-uploading it.
+type UnfinishedUpload struct {
+	ID         string `json:"id"`
+	SiaPath    string `json:"siapath"`
+	LocalPath  string `json:"localpath"`
+	ChunkIndex uint64 `json:"chunkindex"`
+	MerkleRoot string `json:"merkleroot"`
+	Size       uint64 `json:"size"`
+}
+
+// RenterUnfinishedUploadsGET contains the /renter/uploads/unfinished GET API
+// response.
+type RenterUnfinishedUploadsGET struct {
+	Uploads []UnfinishedUpload `json:"uploads"`
+}
@@ -0,0 +1,166 @@
+// Package cache provides a read-mostly, block-level cache around a
+// downloaded Sia file, making it practical to back something like a
+// FUSE mount with repeated, overlapping reads.
+package cache
+
+import (
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/node/api/client"
+
+	"github.com/NebulousLabs/errors"
+)
+
+const (
+	// DefaultBlockSize is the aligned block size CachedFile fetches and
+	// caches.
+	DefaultBlockSize = uint64(1 << 20) // 1 MiB
+
+	// DefaultLocalCacheSize bounds how many bytes of blocks a single
+	// CachedFile keeps for itself.
+	DefaultLocalCacheSize = uint64(100 << 20) // 100 MiB
+
+	// DefaultGlobalCacheSize bounds how many bytes of blocks are kept
+	// across every CachedFile in the process.
+	DefaultGlobalCacheSize = uint64(1 << 30) // 1 GiB
+)
+
+// globalCache is shared by every CachedFile in the process, so that hot
+// blocks stay cached even after the CachedFile that first fetched them is no
+// longer used.
+var globalCache = newLRU(DefaultGlobalCacheSize)
+
+// fetches coalesces concurrent fetches of the same block across every
+// CachedFile, so that N concurrent readers of a hot region cause one
+// network request.
+var fetches = newFetchGroup()
+
+// blockFetcher is the subset of *client.Client that CachedFile needs to pull
+// a missing block from the renter. It exists so tests can exercise
+// CachedFile's caching behavior with a fake that doesn't hit the network.
+type blockFetcher interface {
+	RenterDownloadHTTPResponseGet(siaPath string, offset, length uint64) ([]byte, error)
+}
+
+// clientIDs assigns a stable, process-unique string to every *client.Client
+// CachedFile is ever opened with, so blocks cached for one renter's client
+// can never be handed back to another. A plain pointer address isn't good
+// enough for this: once a *client.Client is garbage collected, Go is free to
+// reuse its address for an unrelated client, which would let a later renter
+// silently read an earlier one's cached blocks. Keeping every client we've
+// ever seen as a map key holds a reference to it for the life of the
+// process, so its address can never be recycled.
+var (
+	clientIDsMu  sync.Mutex
+	clientIDs    = make(map[*client.Client]string)
+	nextClientID int
+)
+
+// clientID returns the stable ID for c, assigning it one the first time c is
+// seen.
+func clientID(c *client.Client) string {
+	clientIDsMu.Lock()
+	defer clientIDsMu.Unlock()
+	if id, ok := clientIDs[c]; ok {
+		return id
+	}
+	nextClientID++
+	id := strconv.Itoa(nextClientID)
+	clientIDs[c] = id
+	return id
+}
+
+// CachedFile is an io.ReaderAt around a Sia file that services reads by
+// fetching aligned, fixed-size blocks and caching them in a per-file and a
+// global LRU.
+type CachedFile struct {
+	client    blockFetcher
+	clientKey string
+	siaPath   string
+	filesize  uint64
+	blockSize uint64
+	local     *lru
+}
+
+// Open returns a CachedFile for siaPath, which is filesize bytes large.
+func Open(c *client.Client, siaPath string, filesize uint64) *CachedFile {
+	return &CachedFile{
+		client:    c,
+		clientKey: clientID(c),
+		siaPath:   siaPath,
+		filesize:  filesize,
+		blockSize: DefaultBlockSize,
+		local:     newLRU(DefaultLocalCacheSize),
+	}
+}
+
+// ReadAt implements io.ReaderAt, serving p from cached blocks and fetching
+// any that are missing.
+func (cf *CachedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if uint64(off) >= cf.filesize {
+		return 0, io.EOF
+	}
+
+	var n int
+	for n < len(p) {
+		offset := uint64(off) + uint64(n)
+		if offset >= cf.filesize {
+			break
+		}
+		blockIndex := offset / cf.blockSize
+		block, err := cf.block(blockIndex)
+		if err != nil {
+			return n, err
+		}
+		blockStart := blockIndex * cf.blockSize
+		copied := copy(p[n:], block[offset-blockStart:])
+		if copied == 0 {
+			break
+		}
+		n += copied
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// block returns the blockIndex'th block of the file, serving it from the
+// per-file cache, then the global cache, and only then fetching it from the
+// renter.
+func (cf *CachedFile) block(blockIndex uint64) ([]byte, error) {
+	// siaPath is only unique within the renter that owns it, so the key
+	// must also identify which client's renter it was fetched through -
+	// otherwise two CachedFiles opened for different renters that happen to
+	// share a siaPath would transparently read each other's cached blocks.
+	key := cf.clientKey + "#" + cf.siaPath + "#" + strconv.FormatUint(blockIndex, 10)
+
+	if data, ok := cf.local.get(key); ok {
+		return data, nil
+	}
+	if data, ok := globalCache.get(key); ok {
+		cf.local.set(key, data)
+		return data, nil
+	}
+
+	data, err := fetches.do(key, func() ([]byte, error) {
+		offset := blockIndex * cf.blockSize
+		length := cf.blockSize
+		if offset+length > cf.filesize {
+			length = cf.filesize - offset
+		}
+		return cf.client.RenterDownloadHTTPResponseGet(cf.siaPath, offset, length)
+	})
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to fetch block")
+	}
+
+	cf.local.set(key, data)
+	globalCache.set(key, data)
+	return data, nil
+}
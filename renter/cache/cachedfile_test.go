@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// testClientSeq gives each call to newTestCachedFile its own clientKey, so
+// tests running in parallel never collide in the shared global cache.
+var testClientSeq int
+
+// countingFetcher is a blockFetcher that records one call per (offset,
+// length) pair it's asked to fetch, so tests can assert on how many times
+// the renter was actually hit.
+type countingFetcher struct {
+	mu    sync.Mutex
+	calls int
+	data  []byte
+}
+
+func newCountingFetcher(size int) *countingFetcher {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return &countingFetcher{data: data}
+}
+
+func (f *countingFetcher) RenterDownloadHTTPResponseGet(siaPath string, offset, length uint64) ([]byte, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	end := offset + length
+	if end > uint64(len(f.data)) {
+		end = uint64(len(f.data))
+	}
+	return f.data[offset:end], nil
+}
+
+func (f *countingFetcher) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// newTestCachedFile returns a CachedFile backed by fetcher with a small
+// block size, so a handful of reads exercises multiple blocks without
+// needing a large fixture.
+func newTestCachedFile(fetcher *countingFetcher, filesize uint64) *CachedFile {
+	testClientSeq++
+	return &CachedFile{
+		client:    fetcher,
+		clientKey: "test-" + strconv.Itoa(testClientSeq),
+		siaPath:   "testpath",
+		filesize:  filesize,
+		blockSize: 16,
+		local:     newLRU(DefaultLocalCacheSize),
+	}
+}
+
+// TestCachedFileSequentialReadsHitCache verifies that reading a file byte by
+// byte sequentially only fetches each block once, instead of once per read.
+func TestCachedFileSequentialReadsHitCache(t *testing.T) {
+	fetcher := newCountingFetcher(64)
+	cf := newTestCachedFile(fetcher, 64)
+
+	buf := make([]byte, 1)
+	for off := int64(0); off < 64; off++ {
+		if _, err := cf.ReadAt(buf, off); err != nil {
+			t.Fatalf("ReadAt(%d): %v", off, err)
+		}
+		if buf[0] != byte(off) {
+			t.Fatalf("ReadAt(%d): got %d, want %d", off, buf[0], off)
+		}
+	}
+
+	if got, want := fetcher.callCount(), 4; got != want {
+		t.Fatalf("expected one fetch per 16-byte block (%d blocks), got %d fetches", want, got)
+	}
+}
+
+// TestCachedFileStridedReadsHitCache verifies that revisiting a block already
+// read earlier - as a strided access pattern does - is served from cache
+// instead of triggering another fetch.
+func TestCachedFileStridedReadsHitCache(t *testing.T) {
+	fetcher := newCountingFetcher(64)
+	cf := newTestCachedFile(fetcher, 64)
+
+	buf := make([]byte, 1)
+	// Touch every block once, with a stride of 16 so each read lands in a
+	// different block (offsets 0, 16, 32, 48).
+	for _, off := range []int64{0, 16, 32, 48} {
+		if _, err := cf.ReadAt(buf, off); err != nil {
+			t.Fatalf("ReadAt(%d): %v", off, err)
+		}
+	}
+	if got, want := fetcher.callCount(), 4; got != want {
+		t.Fatalf("after first pass: got %d fetches, want %d", got, want)
+	}
+
+	// Revisit the same blocks, in reverse stride order. None of these
+	// should trigger a new fetch since every block is already cached.
+	for _, off := range []int64{48, 32, 16, 0} {
+		if _, err := cf.ReadAt(buf, off); err != nil {
+			t.Fatalf("ReadAt(%d): %v", off, err)
+		}
+	}
+	if got, want := fetcher.callCount(), 4; got != want {
+		t.Fatalf("after revisiting cached blocks: got %d fetches, want %d (no new fetches)", got, want)
+	}
+}
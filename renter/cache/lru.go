@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is a byte-bounded least-recently-used cache of opaque blocks. It's
+// safe for concurrent use.
+type lru struct {
+	mu       sync.Mutex
+	maxBytes uint64
+	curBytes uint64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// lruEntry is the value stored in the lru's linked list.
+type lruEntry struct {
+	key  string
+	data []byte
+}
+
+// newLRU creates an lru bounded to maxBytes of cached block data.
+func newLRU(maxBytes uint64) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the block for key, promoting it to most-recently-used.
+func (c *lru) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*lruEntry).data, true
+}
+
+// set inserts or updates the block for key, evicting the least-recently-used
+// blocks until the cache is back within its byte budget.
+func (c *lru) set(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.curBytes -= uint64(len(elem.Value.(*lruEntry).data))
+		elem.Value.(*lruEntry).data = data
+		c.curBytes += uint64(len(data))
+		c.ll.MoveToFront(elem)
+	} else {
+		elem := c.ll.PushFront(&lruEntry{key: key, data: data})
+		c.items[key] = elem
+		c.curBytes += uint64(len(data))
+	}
+
+	for c.curBytes > c.maxBytes {
+		back := c.ll.Back()
+		if back == nil {
+			break
+		}
+		c.ll.Remove(back)
+		entry := back.Value.(*lruEntry)
+		delete(c.items, entry.key)
+		c.curBytes -= uint64(len(entry.data))
+	}
+}
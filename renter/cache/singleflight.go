@@ -0,0 +1,47 @@
+package cache
+
+import "sync"
+
+// call represents an in-flight or completed fetch for a single key.
+type call struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// fetchGroup coalesces concurrent fetches for the same key onto a single
+// call to fn, so that N concurrent readers of a hot block cause one
+// upstream request instead of N.
+type fetchGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// newFetchGroup creates an empty fetchGroup.
+func newFetchGroup() *fetchGroup {
+	return &fetchGroup{calls: make(map[string]*call)}
+}
+
+// do runs fn for key, or waits for an identical call already in flight and
+// returns its result.
+func (g *fetchGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.data, c.err
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.data, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return c.data, c.err
+}
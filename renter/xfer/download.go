@@ -0,0 +1,50 @@
+package xfer
+
+import (
+	"context"
+	"time"
+)
+
+// DownloadManager deduplicates concurrent downloads that share the same key
+// (typically "siaPath:destination") and retries a failed download with
+// exponential backoff.
+type DownloadManager struct {
+	tm *engine
+}
+
+// NewDownloadManager creates a DownloadManager with the given retry policy.
+func NewDownloadManager(maxRetries int, baseBackoff time.Duration) *DownloadManager {
+	return &DownloadManager{tm: NewManager(maxRetries, baseBackoff)}
+}
+
+// Download starts (or joins, if already in flight) the download identified
+// by key, running fn to perform the actual transfer, and returns a transfer
+// id that can be passed to Watch and Cancel. total is reported on Progress
+// so watchers can render a completion percentage. The download keeps
+// running until every caller that joined it has called Cancel.
+func (dm *DownloadManager) Download(key string, total uint64, fn func(ctx context.Context) error) string {
+	t := dm.tm.subscribe(key, func(t *transfer) {
+		t.update(Progress{Status: StatusRunning, Total: total})
+		err := withBackoff(t.ctx, dm.tm.maxRetries, dm.tm.baseBackoff, func(attempt int) error {
+			t.update(Progress{Status: StatusRunning, Total: total, Attempt: attempt})
+			return fn(t.ctx)
+		})
+		if err != nil {
+			t.update(Progress{Status: StatusFailed, Err: err})
+			return
+		}
+		t.update(Progress{Status: StatusCompleted, Received: total, Total: total})
+	})
+	return t.id
+}
+
+// Watch returns a channel of Progress updates for the given download id.
+func (dm *DownloadManager) Watch(id string) <-chan Progress {
+	return dm.tm.watch(id)
+}
+
+// Cancel unsubscribes the caller from the download with the given id,
+// aborting the underlying download once every subscriber has cancelled.
+func (dm *DownloadManager) Cancel(id string) {
+	dm.tm.cancelID(id)
+}
@@ -0,0 +1,252 @@
+// Package xfer implements a transfer manager that sits between the renter
+// client and the renter HTTP API. It deduplicates concurrent uploads and
+// downloads of the same file, reference-counts the callers watching a
+// transfer so that a transfer is only aborted once every subscriber has
+// cancelled, and retries failed transfers with exponential backoff.
+package xfer
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/errors"
+)
+
+// Status is the lifecycle state of a transfer.
+type Status int
+
+// Transfer lifecycle states.
+const (
+	StatusPending Status = iota
+	StatusRunning
+	StatusCompleted
+	StatusFailed
+	StatusCancelled
+)
+
+// Progress is sent on a transfer's Watch channel whenever its state changes.
+type Progress struct {
+	ID       string
+	Status   Status
+	Received uint64
+	Total    uint64
+	Attempt  int
+	Err      error
+}
+
+// transfer tracks a single deduplicated upload or download and the set of
+// callers currently subscribed to it.
+type transfer struct {
+	id   string
+	key  string
+	ctx  context.Context
+
+	mu       sync.Mutex
+	subs     int
+	progress Progress
+	watchers []chan Progress
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// engine deduplicates transfers keyed by an arbitrary string (e.g.
+// "siaPath:destination"), so that multiple callers asking for the same
+// transfer share one underlying upload or download.
+type engine struct {
+	mu          sync.Mutex
+	transfers   map[string]*transfer
+	byID        map[string]*transfer
+	nextID      int
+	maxRetries  int
+	baseBackoff time.Duration
+}
+
+// NewManager creates an engine with the given retry policy. A maxRetries of
+// 0 disables retries.
+func NewManager(maxRetries int, baseBackoff time.Duration) *engine {
+	return &engine{
+		transfers:   make(map[string]*transfer),
+		byID:        make(map[string]*transfer),
+		maxRetries:  maxRetries,
+		baseBackoff: baseBackoff,
+	}
+}
+
+// subscribe returns the transfer for key, creating and starting it via run if
+// it doesn't exist yet, and increments its subscriber count. The caller must
+// eventually call unsubscribe with the same key and the returned transfer.
+func (tm *engine) subscribe(key string, run func(*transfer)) *transfer {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if t, ok := tm.transfers[key]; ok {
+		t.mu.Lock()
+		t.subs++
+		t.mu.Unlock()
+		return t
+	}
+
+	tm.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &transfer{
+		id:     strconv.Itoa(tm.nextID),
+		key:    key,
+		ctx:    ctx,
+		subs:   1,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	tm.transfers[key] = t
+	tm.byID[t.id] = t
+	go func() {
+		run(t)
+		close(t.done)
+		// Drop the key->transfer dedup entry as soon as the transfer
+		// reaches a terminal state, even if subscribers are still
+		// attached (e.g. still draining Watch). Otherwise a later
+		// subscribe for the same key would rejoin this already-finished
+		// transfer instead of starting a fresh run. The id->transfer entry
+		// is left in place until the last subscriber calls Cancel, so a
+		// concurrent Watch(id) for a transfer that just finished still
+		// finds it instead of ranging over a nil channel forever.
+		tm.mu.Lock()
+		if tm.transfers[key] == t {
+			delete(tm.transfers, key)
+		}
+		tm.mu.Unlock()
+	}()
+	return t
+}
+
+// unsubscribe decrements the subscriber count for key and cancels the
+// underlying transfer once the last subscriber has left. tm.mu is held for
+// the whole call, not just around the map deletes, so it can't interleave
+// with a concurrent subscribe for the same key re-incrementing subs on a
+// transfer this call is about to cancel and remove.
+func (tm *engine) unsubscribe(key string, t *transfer) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	t.mu.Lock()
+	t.subs--
+	subs := t.subs
+	t.mu.Unlock()
+	if subs > 0 {
+		return
+	}
+	t.cancel()
+	if tm.transfers[key] == t {
+		delete(tm.transfers, key)
+	}
+	delete(tm.byID, t.id)
+}
+
+// watch looks up the transfer with the given id and returns a channel of its
+// Progress updates. It returns nil if no such transfer is in flight.
+func (tm *engine) watch(id string) <-chan Progress {
+	tm.mu.Lock()
+	t, ok := tm.byID[id]
+	tm.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return t.Watch()
+}
+
+// cancelID unsubscribes the caller that originally received id, aborting the
+// underlying transfer once every subscriber has cancelled.
+func (tm *engine) cancelID(id string) {
+	tm.mu.Lock()
+	t, ok := tm.byID[id]
+	tm.mu.Unlock()
+	if !ok {
+		return
+	}
+	tm.unsubscribe(t.key, t)
+}
+
+// Watch returns a channel that receives a Progress update every time the
+// transfer changes state, starting with its current state. The channel is
+// closed once the transfer reaches a terminal state.
+func (t *transfer) Watch() <-chan Progress {
+	ch := make(chan Progress, 8)
+	t.mu.Lock()
+	// Send the current snapshot, then publish ch to t.watchers, both while
+	// still holding t.mu. Doing it in this order guarantees update() can't
+	// observe ch until after this send has happened: the channel is fresh
+	// with room to spare, so the send can't block, and it rules out a
+	// concurrent update() racing to close ch before this initial send
+	// completes, which would panic with a send on a closed channel.
+	ch <- t.progress
+	switch t.progress.Status {
+	case StatusCompleted, StatusFailed, StatusCancelled:
+		// The terminal update has already been fanned out, so no later
+		// update() will close ch.
+		close(ch)
+	default:
+		t.watchers = append(t.watchers, ch)
+	}
+	t.mu.Unlock()
+	return ch
+}
+
+// update records a new progress value and fans it out to every watcher,
+// closing their channels once the transfer reaches a terminal state. The
+// terminal update (Completed/Failed/Cancelled) is always delivered - it is
+// never dropped the way a merely informational progress update can be -
+// since callers rely on seeing it to distinguish success from failure. It's
+// delivered from its own goroutine per watcher so a watcher that isn't
+// draining its channel can't block delivery to the others or to this
+// transfer's run goroutine.
+func (t *transfer) update(p Progress) {
+	t.mu.Lock()
+	p.ID = t.id
+	t.progress = p
+	watchers := t.watchers
+	terminal := p.Status == StatusCompleted || p.Status == StatusFailed || p.Status == StatusCancelled
+	t.mu.Unlock()
+
+	for _, ch := range watchers {
+		if terminal {
+			go func(ch chan Progress) {
+				ch <- p
+				close(ch)
+			}(ch)
+			continue
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// withBackoff runs fn up to maxRetries+1 times, sleeping with exponential
+// backoff between attempts, until fn succeeds or ctx is cancelled.
+func withBackoff(ctx context.Context, maxRetries int, base time.Duration, fn func(attempt int) error) error {
+	backoff := base
+	var lastErr error
+	for attempt := 1; attempt <= maxRetries+1; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if attempt == maxRetries+1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return errors.AddContext(lastErr, "exhausted retries")
+}
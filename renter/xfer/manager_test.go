@@ -0,0 +1,69 @@
+package xfer
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSubscribeDedupesRun verifies that subscribing twice to the same key
+// joins the existing transfer instead of starting a second one, so run is
+// only invoked once no matter how many callers subscribe.
+func TestSubscribeDedupesRun(t *testing.T) {
+	tm := NewManager(0, time.Millisecond)
+
+	var runs int32
+	started := make(chan struct{})
+	block := make(chan struct{})
+	run := func(tr *transfer) {
+		atomic.AddInt32(&runs, 1)
+		close(started)
+		<-block
+	}
+
+	t1 := tm.subscribe("key", run)
+	<-started
+	t2 := tm.subscribe("key", run)
+
+	if t1 != t2 {
+		t.Fatal("subscribe returned a different transfer for the same key")
+	}
+	close(block)
+
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Fatalf("run invoked %d times, want 1", got)
+	}
+
+	tm.unsubscribe("key", t1)
+	tm.unsubscribe("key", t2)
+}
+
+// TestUnsubscribeCancelsOnceAllSubscribersLeave verifies that the underlying
+// transfer's context is only cancelled once every subscriber that joined it
+// has unsubscribed, not on the first unsubscribe.
+func TestUnsubscribeCancelsOnceAllSubscribersLeave(t *testing.T) {
+	tm := NewManager(0, time.Millisecond)
+
+	block := make(chan struct{})
+	t1 := tm.subscribe("key", func(tr *transfer) { <-block })
+	t2 := tm.subscribe("key", func(tr *transfer) { <-block })
+	if t1 != t2 {
+		t.Fatal("subscribe returned a different transfer for the same key")
+	}
+
+	tm.unsubscribe("key", t1)
+	select {
+	case <-t1.ctx.Done():
+		t.Fatal("transfer was cancelled after only one of two subscribers left")
+	default:
+	}
+
+	tm.unsubscribe("key", t2)
+	select {
+	case <-t1.ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("transfer was not cancelled after its last subscriber left")
+	}
+
+	close(block)
+}
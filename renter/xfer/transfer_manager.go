@@ -0,0 +1,21 @@
+package xfer
+
+import "time"
+
+// TransferManager is the entry point to this package. It exposes an
+// UploadManager and a DownloadManager, each backed by their own
+// deduplicating, backoff-retried engine, so that uploads and downloads never
+// contend with one another for retry budget.
+type TransferManager struct {
+	Uploads   *UploadManager
+	Downloads *DownloadManager
+}
+
+// New creates a TransferManager whose uploads and downloads retry up to
+// maxRetries times with the given base backoff between attempts.
+func New(maxRetries int, baseBackoff time.Duration) *TransferManager {
+	return &TransferManager{
+		Uploads:   NewUploadManager(maxRetries, baseBackoff),
+		Downloads: NewDownloadManager(maxRetries, baseBackoff),
+	}
+}
@@ -0,0 +1,49 @@
+package xfer
+
+import (
+	"context"
+	"time"
+)
+
+// UploadManager deduplicates concurrent uploads that share the same key
+// (typically "siaPath:localPath") and retries a failed upload with
+// exponential backoff.
+type UploadManager struct {
+	tm *engine
+}
+
+// NewUploadManager creates an UploadManager with the given retry policy.
+func NewUploadManager(maxRetries int, baseBackoff time.Duration) *UploadManager {
+	return &UploadManager{tm: NewManager(maxRetries, baseBackoff)}
+}
+
+// Upload starts (or joins, if already in flight) the upload identified by
+// key, running fn to perform the actual transfer, and returns a transfer id
+// that can be passed to Watch and Cancel. The upload keeps running until
+// every caller that joined it has called Cancel.
+func (um *UploadManager) Upload(key string, fn func(ctx context.Context) error) string {
+	t := um.tm.subscribe(key, func(t *transfer) {
+		t.update(Progress{Status: StatusRunning})
+		err := withBackoff(t.ctx, um.tm.maxRetries, um.tm.baseBackoff, func(attempt int) error {
+			t.update(Progress{Status: StatusRunning, Attempt: attempt})
+			return fn(t.ctx)
+		})
+		if err != nil {
+			t.update(Progress{Status: StatusFailed, Err: err})
+			return
+		}
+		t.update(Progress{Status: StatusCompleted})
+	})
+	return t.id
+}
+
+// Watch returns a channel of Progress updates for the given upload id.
+func (um *UploadManager) Watch(id string) <-chan Progress {
+	return um.tm.watch(id)
+}
+
+// Cancel unsubscribes the caller from the upload with the given id, aborting
+// the underlying upload once every subscriber has cancelled.
+func (um *UploadManager) Cancel(id string) {
+	um.tm.cancelID(id)
+}
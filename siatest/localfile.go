@@ -0,0 +1,109 @@
+package siatest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/crypto"
+
+	"github.com/NebulousLabs/errors"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// crc32cTable is the Castagnoli table used to compute the "crc32c" checksum
+// advertised by some third-party tools.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// LocalFile is a helper struct that represents a file on disk that is
+// tracked by a siatest.TestNode.
+type LocalFile struct {
+	path     string
+	checksum crypto.Hash
+	// Checksums holds additional, non-Sia checksums of the file's contents,
+	// keyed by algorithm name ("sha256", "md5", "crc32c"). They let Sia
+	// interoperate with third-party tools that only publish one of these
+	// hashes for a piece of content.
+	Checksums map[string][]byte
+}
+
+// NewFile creates a new LocalFile of size filesize filled with random data,
+// storing it in SiaTestingDir.
+func NewFile(filesize int) (*LocalFile, error) {
+	fileName := strconv.Itoa(fastrand.Intn(1 << 30))
+	path := filepath.Join(SiaTestingDir, fileName)
+	data := fastrand.Bytes(filesize)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return nil, errors.AddContext(err, "failed to write random file to disk")
+	}
+	return &LocalFile{
+		path:      path,
+		checksum:  crypto.HashAll(data),
+		Checksums: checksumsOf(data),
+	}, nil
+}
+
+// checksumsOf computes every checksum algorithm LocalFile tracks alongside
+// Sia's own blake2b hash.
+func checksumsOf(data []byte) map[string][]byte {
+	sha := sha256.Sum256(data)
+	m := md5.Sum(data)
+	crc := crc32.Checksum(data, crc32cTable)
+	crcBytes := []byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)}
+	return map[string][]byte{
+		"sha256": sha[:],
+		"md5":    m[:],
+		"crc32c": crcBytes,
+	}
+}
+
+// fileName returns the file's name on disk, which is also used as its
+// siaPath once uploaded.
+func (lf *LocalFile) fileName() string {
+	return filepath.Base(lf.path)
+}
+
+// checkIntegrity compares the local file's contents against its recorded
+// checksums. It verifies Sia's own blake2b checksum first, then every other
+// algorithm present in Checksums. Algorithms absent from Checksums are
+// skipped. If any present algorithm mismatches, the returned error
+// aggregates every failure.
+func (lf *LocalFile) checkIntegrity() error {
+	data, err := ioutil.ReadFile(lf.path)
+	if err != nil {
+		return errors.AddContext(err, "failed to read local file")
+	}
+	var blakeErr error
+	if crypto.HashAll(data) != lf.checksum {
+		blakeErr = errors.New("local file's blake2b checksum doesn't match")
+	}
+	return errors.Compose(blakeErr, verifyChecksums(data, lf.Checksums))
+}
+
+// verifyChecksums compares data against every algorithm present in expected,
+// skipping algorithms expected doesn't contain. If any present algorithm
+// mismatches, the returned error aggregates every failure.
+func verifyChecksums(data []byte, expected map[string][]byte) error {
+	actual := checksumsOf(data)
+	var failures []string
+	for algo, want := range expected {
+		have, ok := actual[algo]
+		if !ok {
+			// Not one of the algorithms we know how to compute locally -
+			// nothing to compare against, so there's nothing to fail.
+			continue
+		}
+		if !bytes.Equal(want, have) {
+			failures = append(failures, algo)
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New("checksum mismatch for: " + strings.Join(failures, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package siatest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+	data := []byte("hello world")
+	actual := checksumsOf(data)
+
+	t.Run("matches", func(t *testing.T) {
+		if err := verifyChecksums(data, actual); err != nil {
+			t.Fatalf("verifyChecksums: unexpected error: %v", err)
+		}
+	})
+
+	t.Run("empty expected", func(t *testing.T) {
+		if err := verifyChecksums(data, nil); err != nil {
+			t.Fatalf("verifyChecksums: unexpected error: %v", err)
+		}
+	})
+
+	t.Run("unknown algorithm is skipped", func(t *testing.T) {
+		expected := map[string][]byte{"sha512": []byte("not a real checksum")}
+		if err := verifyChecksums(data, expected); err != nil {
+			t.Fatalf("verifyChecksums: unexpected error for unknown algorithm: %v", err)
+		}
+	})
+
+	t.Run("single mismatch fails", func(t *testing.T) {
+		expected := map[string][]byte{"sha256": []byte("wrong")}
+		if err := verifyChecksums(data, expected); err == nil {
+			t.Fatal("verifyChecksums: expected an error for mismatched sha256")
+		}
+	})
+
+	t.Run("aggregates multiple mismatches", func(t *testing.T) {
+		expected := map[string][]byte{
+			"sha256": []byte("wrong"),
+			"md5":    []byte("also wrong"),
+			"crc32c": actual["crc32c"],
+		}
+		err := verifyChecksums(data, expected)
+		if err == nil {
+			t.Fatal("verifyChecksums: expected an error for mismatched algorithms")
+		}
+		msg := err.Error()
+		if !strings.Contains(msg, "sha256") || !strings.Contains(msg, "md5") {
+			t.Fatalf("verifyChecksums error %q doesn't mention both failing algorithms", msg)
+		}
+		if strings.Contains(msg, "crc32c") {
+			t.Fatalf("verifyChecksums error %q shouldn't mention the matching crc32c algorithm", msg)
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package siatest
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// RemoteFile is a helper struct that represents a file uploaded to the Sia
+// network by a siatest.TestNode.
+type RemoteFile struct {
+	siaPath  string
+	checksum crypto.Hash
+	// Checksums carries the non-Sia checksums recorded for the uploaded
+	// file (see LocalFile.Checksums), so that a download of this file can
+	// be verified against every algorithm the upload computed.
+	Checksums map[string][]byte
+}
@@ -1,22 +1,63 @@
 package siatest
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/renter/cache"
+	"github.com/NebulousLabs/Sia/renter/xfer"
 
 	"github.com/NebulousLabs/errors"
 	"github.com/NebulousLabs/fastrand"
 )
 
-// DownloadToDisk downloads a previously uploaded file. The file will be downloaded
-// to a random location and returned as a TestFile object.
+// transferManager is shared by every TestNode so that tests exercise the
+// same dedup/retry code path production callers use, rather than hitting
+// the renter HTTP API directly.
+var transferManager = xfer.New(5, 250*time.Millisecond)
+
+// clientIDs assigns a stable, process-unique string to every *client.Client
+// Upload or WaitForDownload is ever called with, so a transfer dedup key can
+// identify which renter's client it belongs to. A plain pointer address
+// isn't good enough for this: once a *client.Client is garbage collected, Go
+// is free to reuse its address for an unrelated client, which would let two
+// different TestNodes silently dedupe against each other's transfers. This
+// mirrors the clientID pattern in renter/cache.
+var (
+	clientIDsMu  sync.Mutex
+	clientIDs    = make(map[*client.Client]string)
+	nextClientID int
+)
+
+// clientID returns the stable ID for c, assigning it one the first time c is
+// seen.
+func clientID(c *client.Client) string {
+	clientIDsMu.Lock()
+	defer clientIDsMu.Unlock()
+	if id, ok := clientIDs[c]; ok {
+		return id
+	}
+	nextClientID++
+	id := strconv.Itoa(nextClientID)
+	clientIDs[c] = id
+	return id
+}
+
+// DownloadToDisk downloads a previously uploaded file. The file will be
+// downloaded to a random location and returned as a LocalFile object.
+// RenterDownloadGet writes the file to disk server-side and returns no
+// response body, so unlike DownloadByStream there's no X-Sia-Hash header to
+// verify here; checkIntegrity still confirms the result against rf's
+// recorded checksums once the download finishes.
 func (tn *TestNode) DownloadToDisk(rf *RemoteFile, async bool) (*LocalFile, error) {
 	fi, err := tn.FileInfo(rf)
 	if err != nil {
@@ -30,31 +71,85 @@ func (tn *TestNode) DownloadToDisk(rf *RemoteFile, async bool) (*LocalFile, erro
 	}
 	// Create the TestFile
 	lf := &LocalFile{
-		path:     dest,
-		checksum: rf.checksum,
+		path:      dest,
+		checksum:  rf.checksum,
+		Checksums: rf.Checksums,
 	}
 	// If we download the file asynchronously we are done
 	if async {
 		return lf, nil
 	}
-	// Verify checksum if we downloaded the file blocking
+	// Wait for the download to finish and verify its checksum. This goes
+	// through the same dedup/retry path production callers use.
+	if err := tn.WaitForDownload(lf, rf); err != nil {
+		return lf, errors.AddContext(err, "downloaded file's checksum doesn't match")
+	}
+	return lf, nil
+}
+
+// DownloadToDiskConcurrent downloads a previously uploaded file the same way
+// DownloadToDisk does, except it fetches the file in concurrent,
+// independently-retried parts as configured by opts. It is intended for
+// large files where a single-stream download would leave bandwidth unused.
+func (tn *TestNode) DownloadToDiskConcurrent(rf *RemoteFile, opts client.DownloadOptions) (*LocalFile, error) {
+	fi, err := tn.FileInfo(rf)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to retrieve FileInfo")
+	}
+	fileName := strconv.Itoa(fastrand.Intn(math.MaxInt32))
+	dest := filepath.Join(SiaTestingDir, fileName)
+	if err := tn.DownloadFile(rf.siaPath, dest, fi.Filesize, opts); err != nil {
+		return nil, errors.AddContext(err, "failed to download file")
+	}
+	lf := &LocalFile{
+		path:      dest,
+		checksum:  rf.checksum,
+		Checksums: rf.Checksums,
+	}
 	if err := lf.checkIntegrity(); err != nil {
 		return lf, errors.AddContext(err, "downloaded file's checksum doesn't match")
 	}
 	return lf, nil
 }
 
-// DownloadByStream downloads a file and returns its contents as a slice of bytes.
+// OpenCached returns a cache.CachedFile for rf, an io.ReaderAt that services
+// reads from a per-file and global block cache instead of issuing a fresh
+// HTTP request for every call, making it suitable for backing a
+// FUSE-mounted, read-mostly view of the file.
+func (tn *TestNode) OpenCached(rf *RemoteFile) (*cache.CachedFile, error) {
+	fi, err := tn.FileInfo(rf)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to retrieve FileInfo")
+	}
+	return cache.Open(tn.Client, rf.siaPath, fi.Filesize), nil
+}
+
+// DownloadByStream downloads a file and returns its contents as a slice of
+// bytes. Besides checking the downloaded bytes against Sia's own blake2b
+// checksum, it verifies every algorithm the server advertised for the
+// response via the X-Sia-Hash header. No renter module in this tree
+// actually sets that header yet, so until one does, this second check is a
+// safe no-op: verifyChecksums has nothing advertised to compare against and
+// always passes. It's wired up now so that the moment a server starts
+// sending X-Sia-Hash, this starts enforcing it without any client-side
+// change.
 func (tn *TestNode) DownloadByStream(rf *RemoteFile) (data []byte, err error) {
 	fi, err := tn.FileInfo(rf)
 	if err != nil {
 		return nil, errors.AddContext(err, "failed to retrieve FileInfo")
 	}
-	data, err = tn.RenterDownloadHTTPResponseGet(rf.siaPath, 0, fi.Filesize)
-	if err == nil && rf.checksum != crypto.HashAll(data) {
-		err = errors.New("downloaded bytes don't match requested data")
+	var advertised map[string][]byte
+	data, advertised, err = tn.RenterDownloadHTTPResponseGetWithChecksums(rf.siaPath, 0, fi.Filesize)
+	if err != nil {
+		return nil, err
 	}
-	return
+	if rf.checksum != crypto.HashAll(data) {
+		return data, errors.New("downloaded bytes don't match requested data")
+	}
+	if err := verifyChecksums(data, advertised); err != nil {
+		return data, errors.AddContext(err, "server-advertised checksum verification failed")
+	}
+	return data, nil
 }
 
 // DownloadInfo returns the DownloadInfo struct of a file. If it returns nil,
@@ -116,20 +211,35 @@ func (tn *TestNode) FileInfo(rf *RemoteFile) (modules.FileInfo, error) {
 	return modules.FileInfo{}, errors.New("file is not tracked by the renter")
 }
 
-// Upload uses the node to upload the file.
+// Upload uses the node to upload the file. It goes through the shared
+// transferManager so that an upload already in flight for the same file is
+// joined instead of duplicated.
 func (tn *TestNode) Upload(lf *LocalFile, dataPieces, parityPieces uint64) (*RemoteFile, error) {
-	// Upload file
-	err := tn.RenterUploadPost(lf.path, "/"+lf.fileName(), dataPieces, parityPieces)
-	if err != nil {
-		return nil, err
-	}
 	// Create remote file object
 	rf := &RemoteFile{
-		siaPath:  lf.fileName(),
-		checksum: lf.checksum,
+		siaPath:   lf.fileName(),
+		checksum:  lf.checksum,
+		Checksums: lf.Checksums,
+	}
+	// Upload file. The key is scoped to this node's client so that two
+	// TestNodes uploading to the same siaPath from the same local path -
+	// siaPath is only unique within one renter's namespace, not across the
+	// test network - never dedupe against each other.
+	key := clientID(tn.Client) + ":" + rf.siaPath + ":" + lf.path
+	id := transferManager.Uploads.Upload(key, func(ctx context.Context) error {
+		return tn.RenterUploadPost(lf.path, "/"+lf.fileName(), dataPieces, parityPieces)
+	})
+	defer transferManager.Uploads.Cancel(id)
+	for p := range transferManager.Uploads.Watch(id) {
+		if p.Status == xfer.StatusFailed {
+			return rf, errors.AddContext(p.Err, "failed to upload file")
+		}
+		if p.Status == xfer.StatusCompleted {
+			break
+		}
 	}
 	// Make sure renter tracks file
-	_, err = tn.FileInfo(rf)
+	_, err := tn.FileInfo(rf)
 	if err != nil {
 		return rf, errors.AddContext(err, "uploaded file is not tracked by the renter")
 	}
@@ -169,27 +279,94 @@ func (tn *TestNode) UploadNewFileBlocking(filesize int, dataPieces uint64, parit
 	return
 }
 
+// UploadNewFileBlockingResumable behaves like UploadNewFileBlocking, except
+// the upload goes through UploadBlockingResumable instead of Upload, so that
+// a renter restart partway through the upload resumes from the renter's
+// persisted chunk manifest rather than re-uploading the whole file.
+func (tn *TestNode) UploadNewFileBlockingResumable(filesize int, dataPieces uint64, parityPieces uint64) (rf *RemoteFile, err error) {
+	lf, err := NewFile(filesize)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to create file")
+	}
+	return tn.UploadBlockingResumable(lf, dataPieces, parityPieces)
+}
+
+// UnfinishedUpload returns the persisted chunk manifest entry for rf, if the
+// renter has one, or nil if the upload either hasn't started or already
+// completed. It's used to detect uploads that can be resumed.
+func (tn *TestNode) UnfinishedUpload(rf *RemoteFile) (*api.UnfinishedUpload, error) {
+	uploads, err := tn.RenterUnfinishedUploadsGet()
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to retrieve unfinished uploads")
+	}
+	for _, u := range uploads {
+		if u.SiaPath == rf.siaPath {
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
+
+// UploadBlockingResumable uploads lf the same way Upload does, except that if
+// the renter already has a persisted chunk manifest for rf's siaPath - for
+// example because a previous upload of the same file was interrupted by a
+// restart - it resumes from that manifest instead of re-uploading every
+// chunk. It then waits for the upload to reach full progress and redundancy.
+func (tn *TestNode) UploadBlockingResumable(lf *LocalFile, dataPieces, parityPieces uint64) (rf *RemoteFile, err error) {
+	rf = &RemoteFile{
+		siaPath:   lf.fileName(),
+		checksum:  lf.checksum,
+		Checksums: lf.Checksums,
+	}
+	u, err := tn.UnfinishedUpload(rf)
+	if err != nil {
+		return rf, err
+	}
+	if u != nil {
+		if err = tn.RenterResumeUploadPost(u.ID, lf.path); err != nil {
+			return rf, errors.AddContext(err, "failed to resume upload")
+		}
+	} else if rf, err = tn.Upload(lf, dataPieces, parityPieces); err != nil {
+		return rf, errors.AddContext(err, "failed to start upload")
+	}
+	if err = tn.WaitForUploadProgress(rf, 1); err != nil {
+		return rf, err
+	}
+	err = tn.WaitForUploadRedundancy(rf, float64(dataPieces+parityPieces)/float64(dataPieces))
+	return rf, err
+}
+
 // WaitForDownload waits for the download of a file to finish. If a file wasn't
 // scheduled for download it will return instantly without an error. If parent
 // is provided, it will compare the contents of the downloaded file to the
 // contents of tf2 after the download is finished. WaitForDownload also
 // verifies the checksum of the downloaded file.
 func (tn *TestNode) WaitForDownload(lf *LocalFile, rf *RemoteFile) error {
-	err := Retry(1000, 100*time.Millisecond, func() error {
-		file, err := tn.DownloadInfo(lf, rf)
-		if err != nil {
-			return errors.AddContext(err, "couldn't retrieve DownloadInfo")
-		}
-		if file == nil {
+	// Scope the key to this node's client for the same reason Upload does.
+	key := clientID(tn.Client) + ":" + rf.siaPath + ":" + lf.path
+	id := transferManager.Downloads.Download(key, 0, func(ctx context.Context) error {
+		return Retry(1000, 100*time.Millisecond, func() error {
+			file, err := tn.DownloadInfo(lf, rf)
+			if err != nil {
+				return errors.AddContext(err, "couldn't retrieve DownloadInfo")
+			}
+			if file == nil {
+				return nil
+			}
+			if !file.Completed {
+				return errors.New("download hasn't finished yet")
+			}
 			return nil
+		})
+	})
+	defer transferManager.Downloads.Cancel(id)
+	for p := range transferManager.Downloads.Watch(id) {
+		if p.Status == xfer.StatusFailed {
+			return p.Err
 		}
-		if !file.Completed {
-			return errors.New("download hasn't finished yet")
+		if p.Status == xfer.StatusCompleted {
+			break
 		}
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 	// Verify checksum
 	return lf.checkIntegrity()